@@ -5,19 +5,66 @@ package main
 
 import (
 	"bufio"
+	"encoding/csv"
+	"encoding/json"
+	"flag"
 	"fmt"
+	"io"
 	"os"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/ColombianJoker/go-book/decompress"
 )
 
+// occurrence records where a line was first seen.
+type occurrence struct {
+	File   string
+	Lineno int
+}
+
+// dupRecord is one reportable duplicate: its text, how many times it
+// occurred, the files it occurred in, and where it was first seen.
+type dupRecord struct {
+	Line      string
+	Count     int
+	Files     []string
+	FirstSeen occurrence
+}
+
 func main() {
+	format := flag.String("format", "text", "Output format: text, json, ndjson, csv, or tsv")
+	minCount := flag.Int("min-count", 2, "Only report lines occurring at least this many times")
+	sortBy := flag.String("sort", "count", "Sort output by: count, line, or file")
+	reverse := flag.Bool("reverse", false, "Reverse the sort order")
+	withLinenos := flag.Bool("with-linenos", false, "Include each file's line numbers for every duplicate (json, ndjson, csv, and tsv formats; text output does not include them)")
+	flag.Parse()
+
+	switch *format {
+	case "text", "json", "ndjson", "csv", "tsv":
+	default:
+		fmt.Fprintf(os.Stderr, "dup2: -format must be one of text, json, ndjson, csv, tsv; got %q\n", *format)
+		os.Exit(1)
+	}
+	switch *sortBy {
+	case "count", "line", "file":
+	default:
+		fmt.Fprintf(os.Stderr, "dup2: -sort must be one of count, line, file; got %q\n", *sortBy)
+		os.Exit(1)
+	}
+
 	// counts maps a line to its total occurrences
 	counts := make(map[string]int)
-	// fileOccurrences maps a line to a set (map[string]bool) of filenames where it appeared
-	fileOccurrences := make(map[string]map[string]bool)
+	// fileOccurrences maps a line to the line numbers it occurred at in
+	// each file it appeared in
+	fileOccurrences := make(map[string]map[string][]int)
+	// firstSeen maps a line to the file and line number it first appeared at
+	firstSeen := make(map[string]occurrence)
 
-	files := os.Args[1:]
+	files := flag.Args()
 	if len(files) == 0 {
-		countLines(os.Stdin, counts, fileOccurrences, "stdin") // Pass "stdin" as filename for standard input
+		countLines(os.Stdin, counts, fileOccurrences, firstSeen, "stdin") // Pass "stdin" as filename for standard input
 	} else {
 		for _, arg := range files {
 			f, err := os.Open(arg)
@@ -25,40 +72,201 @@ func main() {
 				fmt.Fprintf(os.Stderr, "dup2: %v\n", err)
 				continue
 			}
-			countLines(f, counts, fileOccurrences, arg) // Pass the filename
-			f.Close()
+			rc, err := decompress.Open(f, "", "", arg)
+			if err != nil {
+				fmt.Fprintf(os.Stderr, "dup2: %v\n", err)
+				f.Close()
+				continue
+			}
+			countLines(rc, counts, fileOccurrences, firstSeen, arg) // Pass the filename
+			rc.Close()
 		}
 	}
 
+	var records []dupRecord
 	for line, n := range counts {
-		if n > 1 {
-			fmt.Printf("%d\t%s", n, line)
-			if fileset, ok := fileOccurrences[line]; ok {
-				fmt.Print("\t(files:")
-				for filename := range fileset {
-					fmt.Printf(" %s", filename)
-				}
-				fmt.Println(")")
+		if n < *minCount {
+			continue
+		}
+		var fileList []string
+		for filename := range fileOccurrences[line] {
+			fileList = append(fileList, filename)
+		}
+		sort.Strings(fileList)
+		records = append(records, dupRecord{Line: line, Count: n, Files: fileList, FirstSeen: firstSeen[line]})
+	}
+	sortRecords(records, *sortBy, *reverse)
+
+	if err := writeRecords(os.Stdout, records, fileOccurrences, *format, *withLinenos); err != nil {
+		fmt.Fprintf(os.Stderr, "dup2: %v\n", err)
+		os.Exit(1)
+	}
+}
+
+// sortRecords orders records by the requested key, ascending unless
+// reverse is set, breaking ties on Line so the output is deterministic
+// regardless of the map iteration order records was built from.
+func sortRecords(records []dupRecord, by string, reverse bool) {
+	sort.SliceStable(records, func(i, j int) bool {
+		var less bool
+		switch by {
+		case "line":
+			less = records[i].Line < records[j].Line
+		case "file":
+			if records[i].FirstSeen.File != records[j].FirstSeen.File {
+				less = records[i].FirstSeen.File < records[j].FirstSeen.File
+			} else {
+				less = records[i].Line < records[j].Line
+			}
+		default: // "count"
+			if records[i].Count != records[j].Count {
+				less = records[i].Count < records[j].Count
 			} else {
-				fmt.Println() // Newline if no files were recorded (e.g., if from stdin only and not handled specifically)
+				less = records[i].Line < records[j].Line
+			}
+		}
+		if reverse {
+			return !less
+		}
+		return less
+	})
+}
+
+// writeRecords renders records to w in the given format.
+func writeRecords(w io.Writer, records []dupRecord, fileOccurrences map[string]map[string][]int, format string, withLinenos bool) error {
+	switch format {
+	case "json":
+		return writeJSON(w, records, fileOccurrences, withLinenos)
+	case "ndjson":
+		return writeNDJSON(w, records, fileOccurrences, withLinenos)
+	case "csv":
+		return writeDelimited(w, records, fileOccurrences, withLinenos, ',')
+	case "tsv":
+		return writeDelimited(w, records, fileOccurrences, withLinenos, '\t')
+	default:
+		return writeText(w, records)
+	}
+}
+
+func writeText(w io.Writer, records []dupRecord) error {
+	for _, r := range records {
+		fmt.Fprintf(w, "%d\t%s", r.Count, r.Line)
+		if len(r.Files) > 0 {
+			fmt.Fprint(w, "\t(files:")
+			for _, filename := range r.Files {
+				fmt.Fprintf(w, " %s", filename)
 			}
+			fmt.Fprintln(w, ")")
+		} else {
+			fmt.Fprintln(w) // Newline if no files were recorded (e.g., if from stdin only and not handled specifically)
 		}
 	}
+	return nil
+}
+
+// jsonRecord is the json/ndjson shape: {"line", "count", "files",
+// "first_seen": {"file", "lineno"}}, with an extra "file_linenos" map of
+// filename to line numbers when -with-linenos is set.
+type jsonRecord struct {
+	Line        string           `json:"line"`
+	Count       int              `json:"count"`
+	Files       []string         `json:"files"`
+	FirstSeen   jsonOccurrence   `json:"first_seen"`
+	FileLinenos map[string][]int `json:"file_linenos,omitempty"`
+}
+
+type jsonOccurrence struct {
+	File   string `json:"file"`
+	Lineno int    `json:"lineno"`
 }
 
-// countLines reads lines from a file, updates counts, and records file occurrences.
-func countLines(f *os.File, counts map[string]int, fileOccurrences map[string]map[string]bool, filename string) {
+func toJSONRecord(r dupRecord, fileOccurrences map[string]map[string][]int, withLinenos bool) jsonRecord {
+	out := jsonRecord{
+		Line:      r.Line,
+		Count:     r.Count,
+		Files:     r.Files,
+		FirstSeen: jsonOccurrence{File: r.FirstSeen.File, Lineno: r.FirstSeen.Lineno},
+	}
+	if withLinenos {
+		out.FileLinenos = fileOccurrences[r.Line]
+	}
+	return out
+}
+
+func writeJSON(w io.Writer, records []dupRecord, fileOccurrences map[string]map[string][]int, withLinenos bool) error {
+	out := make([]jsonRecord, 0, len(records))
+	for _, r := range records {
+		out = append(out, toJSONRecord(r, fileOccurrences, withLinenos))
+	}
+	data, err := json.MarshalIndent(out, "", "  ")
+	if err != nil {
+		return err
+	}
+	_, err = fmt.Fprintln(w, string(data))
+	return err
+}
+
+func writeNDJSON(w io.Writer, records []dupRecord, fileOccurrences map[string]map[string][]int, withLinenos bool) error {
+	enc := json.NewEncoder(w)
+	for _, r := range records {
+		if err := enc.Encode(toJSONRecord(r, fileOccurrences, withLinenos)); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func writeDelimited(w io.Writer, records []dupRecord, fileOccurrences map[string]map[string][]int, withLinenos bool, comma rune) error {
+	cw := csv.NewWriter(w)
+	cw.Comma = comma
+	header := []string{"count", "line", "files", "first_seen_file", "first_seen_lineno"}
+	if withLinenos {
+		header = append(header, "file_linenos")
+	}
+	if err := cw.Write(header); err != nil {
+		return err
+	}
+	for _, r := range records {
+		row := []string{
+			strconv.Itoa(r.Count),
+			r.Line,
+			strings.Join(r.Files, ";"),
+			r.FirstSeen.File,
+			strconv.Itoa(r.FirstSeen.Lineno),
+		}
+		if withLinenos {
+			data, err := json.Marshal(fileOccurrences[r.Line])
+			if err != nil {
+				return err
+			}
+			row = append(row, string(data))
+		}
+		if err := cw.Write(row); err != nil {
+			return err
+		}
+	}
+	cw.Flush()
+	return cw.Error()
+}
+
+// countLines reads lines from a reader, updates counts, and records each
+// line's per-file occurrences (with line numbers) and first sighting.
+func countLines(f io.Reader, counts map[string]int, fileOccurrences map[string]map[string][]int, firstSeen map[string]occurrence, filename string) {
 	input := bufio.NewScanner(f)
+	lineno := 0
 	for input.Scan() {
+		lineno++
 		line := input.Text()
 		counts[line]++
 
-		// Initialize the set of files for this line if it doesn't exist
 		if fileOccurrences[line] == nil {
-			fileOccurrences[line] = make(map[string]bool)
+			fileOccurrences[line] = make(map[string][]int)
+		}
+		fileOccurrences[line][filename] = append(fileOccurrences[line][filename], lineno)
+
+		if _, ok := firstSeen[line]; !ok {
+			firstSeen[line] = occurrence{File: filename, Lineno: lineno}
 		}
-		// Add the current filename to the set for this line
-		fileOccurrences[line][filename] = true
 	}
 	// NOTE: ignoring potential errors from input.Err()
 }
@@ -0,0 +1,166 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"strings"
+	"testing"
+)
+
+func TestCountLines(t *testing.T) {
+	counts := make(map[string]int)
+	fileOccurrences := make(map[string]map[string][]int)
+	firstSeen := make(map[string]occurrence)
+
+	countLines(strings.NewReader("a\nb\na\n"), counts, fileOccurrences, firstSeen, "one.txt")
+	countLines(strings.NewReader("a\nc\n"), counts, fileOccurrences, firstSeen, "two.txt")
+
+	if counts["a"] != 3 {
+		t.Errorf("counts[a] = %d, want 3", counts["a"])
+	}
+	if got := fileOccurrences["a"]["one.txt"]; !equalInts(got, []int{1, 3}) {
+		t.Errorf("fileOccurrences[a][one.txt] = %v, want [1 3]", got)
+	}
+	if got := fileOccurrences["a"]["two.txt"]; !equalInts(got, []int{1}) {
+		t.Errorf("fileOccurrences[a][two.txt] = %v, want [1]", got)
+	}
+	if firstSeen["a"] != (occurrence{File: "one.txt", Lineno: 1}) {
+		t.Errorf("firstSeen[a] = %+v, want {one.txt 1}", firstSeen["a"])
+	}
+}
+
+func equalInts(a, b []int) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if a[i] != b[i] {
+			return false
+		}
+	}
+	return true
+}
+
+func TestSortRecords(t *testing.T) {
+	records := []dupRecord{
+		{Line: "b", Count: 1, FirstSeen: occurrence{File: "z.txt"}},
+		{Line: "a", Count: 3, FirstSeen: occurrence{File: "y.txt"}},
+		{Line: "c", Count: 2, FirstSeen: occurrence{File: "x.txt"}},
+	}
+
+	sortRecords(records, "count", false)
+	if got := []int{records[0].Count, records[1].Count, records[2].Count}; !equalInts(got, []int{1, 2, 3}) {
+		t.Errorf("sort by count ascending = %v, want [1 2 3]", got)
+	}
+
+	sortRecords(records, "count", true)
+	if got := []int{records[0].Count, records[1].Count, records[2].Count}; !equalInts(got, []int{3, 2, 1}) {
+		t.Errorf("sort by count descending = %v, want [3 2 1]", got)
+	}
+
+	sortRecords(records, "line", false)
+	if got := records[0].Line + records[1].Line + records[2].Line; got != "abc" {
+		t.Errorf("sort by line ascending = %q, want \"abc\"", got)
+	}
+
+	sortRecords(records, "file", false)
+	if got := records[0].FirstSeen.File; got != "x.txt" {
+		t.Errorf("sort by file ascending, first = %q, want \"x.txt\"", got)
+	}
+}
+
+// TestSortRecordsTieBreak guards against the output order depending on
+// the map-iteration order records happened to be built in: equal-count
+// records must always come out in the same (Line-broken) order.
+func TestSortRecordsTieBreak(t *testing.T) {
+	tied := []dupRecord{
+		{Line: "c", Count: 2},
+		{Line: "a", Count: 2},
+		{Line: "b", Count: 2},
+	}
+	want := "abc"
+
+	for i := 0; i < 5; i++ {
+		records := append([]dupRecord(nil), tied...)
+		sortRecords(records, "count", false)
+		got := records[0].Line + records[1].Line + records[2].Line
+		if got != want {
+			t.Fatalf("run %d: sort by count with tied counts = %q, want %q", i, got, want)
+		}
+	}
+}
+
+func TestWriteRecordsText(t *testing.T) {
+	records := []dupRecord{
+		{Line: "dup", Count: 2, Files: []string{"a.txt", "b.txt"}},
+	}
+	var buf bytes.Buffer
+	if err := writeRecords(&buf, records, nil, "text", false); err != nil {
+		t.Fatalf("writeRecords: %v", err)
+	}
+	want := "2\tdup\t(files: a.txt b.txt)\n"
+	if buf.String() != want {
+		t.Errorf("writeRecords text = %q, want %q", buf.String(), want)
+	}
+}
+
+func TestWriteRecordsJSON(t *testing.T) {
+	records := []dupRecord{
+		{Line: "dup", Count: 2, Files: []string{"a.txt"}, FirstSeen: occurrence{File: "a.txt", Lineno: 1}},
+	}
+	fileOccurrences := map[string]map[string][]int{
+		"dup": {"a.txt": {1, 4}},
+	}
+
+	var buf bytes.Buffer
+	if err := writeRecords(&buf, records, fileOccurrences, "json", true); err != nil {
+		t.Fatalf("writeRecords: %v", err)
+	}
+	var out []jsonRecord
+	if err := json.Unmarshal(buf.Bytes(), &out); err != nil {
+		t.Fatalf("json.Unmarshal: %v (%s)", err, buf.String())
+	}
+	if len(out) != 1 {
+		t.Fatalf("len(out) = %d, want 1", len(out))
+	}
+	if out[0].Line != "dup" || out[0].Count != 2 {
+		t.Errorf("out[0] = %+v", out[0])
+	}
+	if got := out[0].FileLinenos["a.txt"]; !equalInts(got, []int{1, 4}) {
+		t.Errorf("FileLinenos[a.txt] = %v, want [1 4]", got)
+	}
+}
+
+func TestWriteRecordsDelimited(t *testing.T) {
+	records := []dupRecord{
+		{Line: "dup", Count: 2, Files: []string{"a.txt", "b.txt"}, FirstSeen: occurrence{File: "a.txt", Lineno: 1}},
+	}
+	var buf bytes.Buffer
+	if err := writeRecords(&buf, records, nil, "csv", false); err != nil {
+		t.Fatalf("writeRecords: %v", err)
+	}
+	lines := strings.Split(strings.TrimSpace(buf.String()), "\n")
+	if len(lines) != 2 {
+		t.Fatalf("got %d lines, want 2 (header + row)", len(lines))
+	}
+	if lines[0] != "count,line,files,first_seen_file,first_seen_lineno" {
+		t.Errorf("header = %q", lines[0])
+	}
+	if lines[1] != "2,dup,a.txt;b.txt,a.txt,1" {
+		t.Errorf("row = %q", lines[1])
+	}
+}
+
+func TestMinCountFilter(t *testing.T) {
+	counts := map[string]int{"once": 1, "twice": 2, "thrice": 3}
+	var kept []string
+	for line, n := range counts {
+		if n < 2 {
+			continue
+		}
+		kept = append(kept, line)
+	}
+	if len(kept) != 2 {
+		t.Errorf("kept = %v, want 2 entries at min-count 2", kept)
+	}
+}
@@ -0,0 +1,51 @@
+// Package decompress transparently decompresses gzip/bzip2 content,
+// shared by regexdownload (fetched HTTP bodies) and dup2 (local files
+// passed on the command line).
+package decompress
+
+import (
+	"compress/bzip2"
+	"compress/gzip"
+	"fmt"
+	"io"
+	"strings"
+)
+
+// readCloser chains a decompressing reader to the underlying stream it
+// was built from. Close releases the decompressor (when it has one of
+// its own, e.g. *gzip.Reader) and then the underlying stream.
+type readCloser struct {
+	io.Reader
+	closer     io.Closer // optional, nil when the decompressor needs no closing (e.g. bzip2)
+	underlying io.Closer
+}
+
+func (d *readCloser) Close() error {
+	var err error
+	if d.closer != nil {
+		err = d.closer.Close()
+	}
+	if cerr := d.underlying.Close(); err == nil {
+		err = cerr
+	}
+	return err
+}
+
+// Open inspects contentType, contentEncoding (either may be empty, e.g.
+// for local files) and the extension of name (a URL or file path) to
+// decide whether rc needs decompressing, wrapping it in the matching
+// reader when it does. When nothing matches, rc is returned unchanged.
+func Open(rc io.ReadCloser, contentType, contentEncoding, name string) (io.ReadCloser, error) {
+	switch {
+	case contentEncoding == "gzip", strings.Contains(contentType, "gzip"), strings.HasSuffix(name, ".gz"):
+		gz, err := gzip.NewReader(rc)
+		if err != nil {
+			return nil, fmt.Errorf("could not open gzip stream: %w", err)
+		}
+		return &readCloser{Reader: gz, closer: gz, underlying: rc}, nil
+	case contentEncoding == "bzip2", strings.Contains(contentType, "bzip2"), strings.HasSuffix(name, ".bz2"):
+		return &readCloser{Reader: bzip2.NewReader(rc), underlying: rc}, nil
+	default:
+		return rc, nil
+	}
+}
@@ -0,0 +1,242 @@
+// Package extractors implements the URL-extraction backends used
+// alongside regexdownload's regex (re*) keys: css, xpath, json, and
+// sitemap. Each backend implements Extractor and is looked up by the
+// prefix of its ini key (the part before the first '.').
+package extractors
+
+import (
+	"bytes"
+	"encoding/json"
+	"encoding/xml"
+	"fmt"
+	"net/url"
+	"strings"
+
+	"github.com/PaesslerAG/jsonpath"
+	"github.com/PuerkitoBio/goquery"
+	"github.com/antchfx/htmlquery"
+)
+
+// Extractor pulls candidate URLs out of a fetched page or file.
+type Extractor interface {
+	Extract(content []byte, baseURL *url.URL) ([]string, error)
+}
+
+// For returns the Extractor registered for prefix, built against query
+// (the ini key's value, e.g. "img.gallery@src" for a css key). ok is
+// false when prefix names no known backend.
+func For(prefix, query string) (e Extractor, ok bool) {
+	switch prefix {
+	case "css":
+		return &cssExtractor{query: query}, true
+	case "xpath":
+		return &xpathExtractor{expr: query}, true
+	case "json":
+		return &jsonExtractor{expr: query}, true
+	case "sitemap":
+		return &sitemapExtractor{}, true
+	default:
+		return nil, false
+	}
+}
+
+// resolveURL resolves ref against base (honoring a page's <base href>
+// when base already reflects it), returning "" for anything unparsable.
+func resolveURL(base *url.URL, ref string) string {
+	ref = strings.TrimSpace(ref)
+	if ref == "" {
+		return ""
+	}
+	u, err := url.Parse(ref)
+	if err != nil {
+		return ""
+	}
+	if base == nil {
+		return u.String()
+	}
+	return base.ResolveReference(u).String()
+}
+
+// splitSelectorAttr splits "selector@attr" into its selector and
+// attribute; a selector with no "@attr" selects the element's text.
+func splitSelectorAttr(query string) (selector, attr string) {
+	if idx := strings.LastIndex(query, "@"); idx != -1 {
+		return query[:idx], query[idx+1:]
+	}
+	return query, ""
+}
+
+// cssExtractor selects elements with a CSS selector and reads either an
+// attribute (selector@attr) or the element's text.
+type cssExtractor struct {
+	query string
+}
+
+func (e *cssExtractor) Extract(content []byte, baseURL *url.URL) ([]string, error) {
+	doc, err := goquery.NewDocumentFromReader(bytes.NewReader(content))
+	if err != nil {
+		return nil, fmt.Errorf("css: could not parse HTML: %w", err)
+	}
+	base := baseURL
+	if href, ok := doc.Find("base[href]").First().Attr("href"); ok {
+		if resolved := resolveURL(baseURL, href); resolved != "" {
+			if u, err := url.Parse(resolved); err == nil {
+				base = u
+			}
+		}
+	}
+
+	selector, attr := splitSelectorAttr(e.query)
+	var found []string
+	doc.Find(selector).Each(func(_ int, sel *goquery.Selection) {
+		var value string
+		var ok bool
+		if attr == "" {
+			value, ok = sel.Text(), true
+		} else {
+			value, ok = sel.Attr(attr)
+		}
+		if !ok {
+			return
+		}
+		if resolved := resolveURL(base, value); resolved != "" {
+			found = append(found, resolved)
+		}
+	})
+	return found, nil
+}
+
+// xpathExtractor evaluates an XPath expression, resolving each matched
+// node's text (or attribute value, for attribute-selecting expressions
+// such as //a/@href) against the page's base URL.
+type xpathExtractor struct {
+	expr string
+}
+
+func (e *xpathExtractor) Extract(content []byte, baseURL *url.URL) ([]string, error) {
+	doc, err := htmlquery.Parse(bytes.NewReader(content))
+	if err != nil {
+		return nil, fmt.Errorf("xpath: could not parse HTML: %w", err)
+	}
+	base := baseURL
+	if baseNode := htmlquery.FindOne(doc, "//base/@href"); baseNode != nil {
+		if resolved := resolveURL(baseURL, htmlquery.InnerText(baseNode)); resolved != "" {
+			if u, err := url.Parse(resolved); err == nil {
+				base = u
+			}
+		}
+	}
+
+	nodes, err := htmlquery.QueryAll(doc, e.expr)
+	if err != nil {
+		return nil, fmt.Errorf("xpath: invalid expression %q: %w", e.expr, err)
+	}
+	var found []string
+	for _, n := range nodes {
+		if resolved := resolveURL(base, htmlquery.InnerText(n)); resolved != "" {
+			found = append(found, resolved)
+		}
+	}
+	return found, nil
+}
+
+// jsonExtractor evaluates a JSONPath expression (e.g. "$.data[*].mediaUrl")
+// against a JSON body, resolving every string result it yields.
+type jsonExtractor struct {
+	expr string
+}
+
+func (e *jsonExtractor) Extract(content []byte, baseURL *url.URL) ([]string, error) {
+	var data interface{}
+	if err := json.Unmarshal(content, &data); err != nil {
+		return nil, fmt.Errorf("json: could not parse body: %w", err)
+	}
+	result, err := jsonpath.Get(e.expr, data)
+	if err != nil {
+		return nil, fmt.Errorf("json: invalid expression %q: %w", e.expr, err)
+	}
+
+	var found []string
+	switch v := result.(type) {
+	case string:
+		if resolved := resolveURL(baseURL, v); resolved != "" {
+			found = append(found, resolved)
+		}
+	case []interface{}:
+		for _, item := range v {
+			if s, ok := item.(string); ok {
+				if resolved := resolveURL(baseURL, s); resolved != "" {
+					found = append(found, resolved)
+				}
+			}
+		}
+	}
+	return found, nil
+}
+
+// sitemapExtractor enumerates URLs out of a sitemap.xml, RSS, or Atom
+// document, trying each schema in turn.
+type sitemapExtractor struct{}
+
+type sitemapURLSet struct {
+	XMLName xml.Name `xml:"urlset"`
+	URLs    []struct {
+		Loc string `xml:"loc"`
+	} `xml:"url"`
+}
+
+type rssFeed struct {
+	XMLName xml.Name `xml:"rss"`
+	Channel struct {
+		Items []struct {
+			Link string `xml:"link"`
+		} `xml:"item"`
+	} `xml:"channel"`
+}
+
+type atomFeed struct {
+	XMLName xml.Name `xml:"feed"`
+	Entries []struct {
+		Links []struct {
+			Href string `xml:"href,attr"`
+		} `xml:"link"`
+	} `xml:"entry"`
+}
+
+func (e *sitemapExtractor) Extract(content []byte, baseURL *url.URL) ([]string, error) {
+	var urlset sitemapURLSet
+	if err := xml.Unmarshal(content, &urlset); err == nil && len(urlset.URLs) > 0 {
+		var found []string
+		for _, u := range urlset.URLs {
+			if resolved := resolveURL(baseURL, u.Loc); resolved != "" {
+				found = append(found, resolved)
+			}
+		}
+		return found, nil
+	}
+
+	var rss rssFeed
+	if err := xml.Unmarshal(content, &rss); err == nil && len(rss.Channel.Items) > 0 {
+		var found []string
+		for _, item := range rss.Channel.Items {
+			if resolved := resolveURL(baseURL, item.Link); resolved != "" {
+				found = append(found, resolved)
+			}
+		}
+		return found, nil
+	}
+
+	var atom atomFeed
+	if err := xml.Unmarshal(content, &atom); err != nil {
+		return nil, fmt.Errorf("sitemap: could not parse as urlset, RSS, or Atom: %w", err)
+	}
+	var found []string
+	for _, entry := range atom.Entries {
+		for _, link := range entry.Links {
+			if resolved := resolveURL(baseURL, link.Href); resolved != "" {
+				found = append(found, resolved)
+			}
+		}
+	}
+	return found, nil
+}
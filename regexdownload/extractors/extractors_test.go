@@ -0,0 +1,176 @@
+package extractors
+
+import (
+	"net/url"
+	"reflect"
+	"testing"
+)
+
+func mustParse(t *testing.T, rawURL string) *url.URL {
+	t.Helper()
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		t.Fatalf("url.Parse(%q): %v", rawURL, err)
+	}
+	return u
+}
+
+func TestCSSExtractorAttr(t *testing.T) {
+	html := `<html><body><a class="item" href="/one.jpg">one</a><a class="item" href="/two.jpg">two</a></body></html>`
+	e := &cssExtractor{query: "a.item@href"}
+	got, err := e.Extract([]byte(html), mustParse(t, "https://example.com/page"))
+	if err != nil {
+		t.Fatalf("Extract: %v", err)
+	}
+	want := []string{"https://example.com/one.jpg", "https://example.com/two.jpg"}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("got %v, want %v", got, want)
+	}
+}
+
+func TestCSSExtractorText(t *testing.T) {
+	html := `<html><body><span class="url">/one.jpg</span></body></html>`
+	e := &cssExtractor{query: "span.url"}
+	got, err := e.Extract([]byte(html), mustParse(t, "https://example.com/page"))
+	if err != nil {
+		t.Fatalf("Extract: %v", err)
+	}
+	want := []string{"https://example.com/one.jpg"}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("got %v, want %v", got, want)
+	}
+}
+
+func TestCSSExtractorHonorsBaseHref(t *testing.T) {
+	html := `<html><head><base href="https://cdn.example.com/assets/"></head><body><a class="item" href="pic.jpg">pic</a></body></html>`
+	e := &cssExtractor{query: "a.item@href"}
+	got, err := e.Extract([]byte(html), mustParse(t, "https://example.com/page"))
+	if err != nil {
+		t.Fatalf("Extract: %v", err)
+	}
+	want := []string{"https://cdn.example.com/assets/pic.jpg"}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("got %v, want %v", got, want)
+	}
+}
+
+func TestXPathExtractor(t *testing.T) {
+	html := `<html><body><a href="/one.jpg">one</a><a href="/two.jpg">two</a></body></html>`
+	e := &xpathExtractor{expr: "//a/@href"}
+	got, err := e.Extract([]byte(html), mustParse(t, "https://example.com/page"))
+	if err != nil {
+		t.Fatalf("Extract: %v", err)
+	}
+	want := []string{"https://example.com/one.jpg", "https://example.com/two.jpg"}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("got %v, want %v", got, want)
+	}
+}
+
+func TestXPathExtractorInvalidExpr(t *testing.T) {
+	e := &xpathExtractor{expr: "???not-an-xpath???"}
+	if _, err := e.Extract([]byte("<html></html>"), nil); err == nil {
+		t.Error("expected an error for an invalid XPath expression")
+	}
+}
+
+func TestJSONExtractorSingleString(t *testing.T) {
+	body := `{"data": {"mediaUrl": "/one.jpg"}}`
+	e := &jsonExtractor{expr: "$.data.mediaUrl"}
+	got, err := e.Extract([]byte(body), mustParse(t, "https://example.com/page"))
+	if err != nil {
+		t.Fatalf("Extract: %v", err)
+	}
+	want := []string{"https://example.com/one.jpg"}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("got %v, want %v", got, want)
+	}
+}
+
+func TestJSONExtractorArray(t *testing.T) {
+	body := `{"items": [{"url": "/one.jpg"}, {"url": "/two.jpg"}]}`
+	e := &jsonExtractor{expr: "$.items[*].url"}
+	got, err := e.Extract([]byte(body), mustParse(t, "https://example.com/page"))
+	if err != nil {
+		t.Fatalf("Extract: %v", err)
+	}
+	want := []string{"https://example.com/one.jpg", "https://example.com/two.jpg"}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("got %v, want %v", got, want)
+	}
+}
+
+func TestJSONExtractorInvalidBody(t *testing.T) {
+	e := &jsonExtractor{expr: "$.x"}
+	if _, err := e.Extract([]byte("not json"), nil); err == nil {
+		t.Error("expected an error for an unparsable JSON body")
+	}
+}
+
+func TestSitemapExtractorURLSet(t *testing.T) {
+	xml := `<?xml version="1.0"?><urlset><url><loc>https://example.com/a</loc></url><url><loc>https://example.com/b</loc></url></urlset>`
+	e := &sitemapExtractor{}
+	got, err := e.Extract([]byte(xml), nil)
+	if err != nil {
+		t.Fatalf("Extract: %v", err)
+	}
+	want := []string{"https://example.com/a", "https://example.com/b"}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("got %v, want %v", got, want)
+	}
+}
+
+func TestSitemapExtractorRSS(t *testing.T) {
+	xml := `<?xml version="1.0"?><rss><channel><item><link>https://example.com/post1</link></item></channel></rss>`
+	e := &sitemapExtractor{}
+	got, err := e.Extract([]byte(xml), nil)
+	if err != nil {
+		t.Fatalf("Extract: %v", err)
+	}
+	want := []string{"https://example.com/post1"}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("got %v, want %v", got, want)
+	}
+}
+
+func TestSitemapExtractorAtom(t *testing.T) {
+	xml := `<?xml version="1.0"?><feed><entry><link href="https://example.com/entry1"/></entry></feed>`
+	e := &sitemapExtractor{}
+	got, err := e.Extract([]byte(xml), nil)
+	if err != nil {
+		t.Fatalf("Extract: %v", err)
+	}
+	want := []string{"https://example.com/entry1"}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("got %v, want %v", got, want)
+	}
+}
+
+func TestSitemapExtractorInvalid(t *testing.T) {
+	e := &sitemapExtractor{}
+	if _, err := e.Extract([]byte("not xml"), nil); err == nil {
+		t.Error("expected an error for unparsable content")
+	}
+}
+
+func TestFor(t *testing.T) {
+	for _, prefix := range []string{"css", "xpath", "json", "sitemap"} {
+		if _, ok := For(prefix, "query"); !ok {
+			t.Errorf("For(%q, ...) ok = false, want true", prefix)
+		}
+	}
+	if _, ok := For("unknown", "query"); ok {
+		t.Error(`For("unknown", ...) ok = true, want false`)
+	}
+}
+
+func TestSplitSelectorAttr(t *testing.T) {
+	sel, attr := splitSelectorAttr("a.item@href")
+	if sel != "a.item" || attr != "href" {
+		t.Errorf("got (%q, %q), want (%q, %q)", sel, attr, "a.item", "href")
+	}
+	sel, attr = splitSelectorAttr("span.text")
+	if sel != "span.text" || attr != "" {
+		t.Errorf("got (%q, %q), want (%q, %q)", sel, attr, "span.text", "")
+	}
+}
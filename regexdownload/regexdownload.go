@@ -1,27 +1,37 @@
 package main
 
 import (
+	"encoding/json"
+	"errors"
 	"flag"
 	"fmt"
 
 	"html"
 	"io"
 	"net/http"
+	"net/http/cookiejar"
 	"net/url"
 	"os"
 	"path" // Used for extracting file extensions from URLs
 	"path/filepath"
 	"regexp"
+	"strconv"
 	"strings"
 	"sync"
 	"time"
 
+	"github.com/ColombianJoker/go-book/decompress"
+	"github.com/ColombianJoker/go-book/regexdownload/extractors"
+	"github.com/vbauerster/mpb/v8"
+	"github.com/vbauerster/mpb/v8/decor"
+	"golang.org/x/term"
 	"gopkg.in/ini.v1"
 )
 
 // ProcessResult holds the outcome of the initial URL parsing.
 type ProcessResult struct {
 	URL            string
+	SectionName    string
 	FinalPrefix    string
 	FoundURLs      []string
 	Err            error
@@ -30,9 +40,96 @@ type ProcessResult struct {
 
 // DownloadResult holds the outcome of a single file download.
 type DownloadResult struct {
-	URL      string
-	Filepath string
-	Err      error
+	URL            string
+	Filepath       string
+	CapturedURLs   []string // Locations matched by capture_redirect_regex during the fetch
+	OutputMessages []string
+	Err            error
+}
+
+// progressReader wraps an io.Reader and reports every Read to a per-file
+// bar and/or a shared total bar. Either bar may be nil.
+type progressReader struct {
+	io.Reader
+	bar   *mpb.Bar
+	total *mpb.Bar
+}
+
+func (r *progressReader) Read(p []byte) (int, error) {
+	n, err := r.Reader.Read(p)
+	if n > 0 {
+		if r.bar != nil {
+			r.bar.IncrBy(n)
+		}
+		if r.total != nil {
+			r.total.IncrBy(n)
+		}
+	}
+	return n, err
+}
+
+// headInfo is what a HEAD probe learns about a URL before downloading.
+type headInfo struct {
+	size         int64 // 0 when the server didn't report a usable Content-Length
+	acceptRanges bool
+}
+
+// probeHead issues a HEAD request to learn a URL's size, used to size the
+// progress bar, and whether the server honors byte ranges, used to decide
+// whether the download can be segmented.
+func probeHead(client *http.Client, rawURL string) headInfo {
+	resp, err := client.Head(rawURL)
+	if err != nil {
+		return headInfo{}
+	}
+	defer resp.Body.Close()
+	info := headInfo{acceptRanges: resp.Header.Get("Accept-Ranges") == "bytes"}
+	if resp.ContentLength > 0 {
+		info.size = resp.ContentLength
+	}
+	return info
+}
+
+// newSizedOrSpinnerBar adds a bar to the pool: a proportional bar when size
+// is known, or an indeterminate spinner when it isn't.
+func newSizedOrSpinnerBar(pool *mpb.Progress, name string, size int64) *mpb.Bar {
+	if size > 0 {
+		return pool.AddBar(size,
+			mpb.PrependDecorators(decor.Name(name, decor.WCSyncSpaceR)),
+			mpb.AppendDecorators(decor.Percentage()),
+		)
+	}
+	return pool.New(0, mpb.SpinnerStyle().PositionLeft(),
+		mpb.PrependDecorators(decor.Name(name, decor.WCSyncSpaceR)),
+	)
+}
+
+// printDuringProgress writes a line to os.Stderr, routed through pool
+// when pool is non-nil. Writing to *mpb.Progress prints the line above
+// the running bars instead of racing their own redraws on the shared fd,
+// which is what happens if os.Stderr is written to directly while a pool
+// is live.
+func printDuringProgress(pool *mpb.Progress, format string, args ...interface{}) {
+	if pool != nil {
+		fmt.Fprintf(pool, format, args...)
+		return
+	}
+	fmt.Fprintf(os.Stderr, format, args...)
+}
+
+// dedupeStrings returns urls with duplicates removed, preserving the
+// order of first occurrence.
+func dedupeStrings(urls []string) []string {
+	seen := make(map[string]bool, len(urls))
+	deduped := urls[:0]
+	for _, u := range urls {
+		if seen[u] {
+			continue
+		}
+		seen[u] = true
+		deduped = append(deduped, u)
+	}
+	return deduped
 }
 
 // cleanPrefix sanitizes a string for use in a filename.
@@ -47,6 +144,397 @@ func cleanPrefix(s string) string {
 	return cleaned
 }
 
+// extractorPrefix reports the extractors-package backend named by an ini
+// key, e.g. "css" for "css.images" or "sitemap" for the bare "sitemap"
+// key. ok is false for keys that aren't an extractor backend (including
+// the existing "re*" keys, which processURL still handles itself).
+func extractorPrefix(keyName string) (prefix string, ok bool) {
+	if keyName == "sitemap" {
+		return "sitemap", true
+	}
+	prefix, _, found := strings.Cut(keyName, ".")
+	if !found {
+		return "", false
+	}
+	switch prefix {
+	case "css", "xpath", "json":
+		return prefix, true
+	default:
+		return "", false
+	}
+}
+
+// redirectPolicy is the per-section redirect behaviour resolved from the
+// ini config: whether to follow at all, how many hops to allow, and an
+// optional regex that, when a redirect's Location matches, captures that
+// Location instead of following it.
+type redirectPolicy struct {
+	follow       bool
+	max          int
+	captureRegex *regexp.Regexp
+}
+
+// resolveRedirectPolicy reads follow_redirects (true|false|max:N) and
+// capture_redirect_regex from section, defaulting to following up to 10
+// redirects when the keys are absent.
+func resolveRedirectPolicy(section *ini.Section) redirectPolicy {
+	policy := redirectPolicy{follow: true, max: 10}
+	if section.HasKey("follow_redirects") {
+		value := section.Key("follow_redirects").String()
+		switch {
+		case value == "false":
+			policy.follow = false
+		case strings.HasPrefix(value, "max:"):
+			if n, err := strconv.Atoi(strings.TrimPrefix(value, "max:")); err == nil {
+				policy.max = n
+			}
+		}
+	}
+	if section.HasKey("capture_redirect_regex") {
+		if re, err := regexp.Compile(section.Key("capture_redirect_regex").String()); err == nil {
+			policy.captureRegex = re
+		}
+	}
+	return policy
+}
+
+// userAgentTransport sets a fixed User-Agent on every outgoing request
+// when one is configured, delegating everything else to rt.
+type userAgentTransport struct {
+	rt        http.RoundTripper
+	userAgent string
+}
+
+func (t *userAgentTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	if t.userAgent == "" {
+		return t.rt.RoundTrip(req)
+	}
+	req = req.Clone(req.Context())
+	req.Header.Set("User-Agent", t.userAgent)
+	return t.rt.RoundTrip(req)
+}
+
+// loadCookieJar reads a simple "domain<TAB>name<TAB>value" cookie file,
+// one cookie per line (blank lines and lines starting with # are
+// skipped), into an in-memory cookie jar.
+func loadCookieJar(path string) (http.CookieJar, error) {
+	jar, err := cookiejar.New(nil)
+	if err != nil {
+		return nil, err
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("could not read cookies file '%s': %w", path, err)
+	}
+	byDomain := make(map[string][]*http.Cookie)
+	for _, line := range strings.Split(string(data), "\n") {
+		line = strings.TrimSpace(line)
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		fields := strings.SplitN(line, "\t", 3)
+		if len(fields) != 3 {
+			continue
+		}
+		domain, name, value := fields[0], fields[1], fields[2]
+		byDomain[domain] = append(byDomain[domain], &http.Cookie{Name: name, Value: value})
+	}
+	for domain, cookies := range byDomain {
+		jar.SetCookies(&url.URL{Scheme: "https", Host: domain}, cookies)
+	}
+	return jar, nil
+}
+
+// httpClientConfig bundles the HTTP client behaviour that is shared
+// between the parse and download phases: the transport (carrying the
+// User-Agent), the cookie jar, the timeout, and the command-line redirect
+// overrides.
+type httpClientConfig struct {
+	transport    http.RoundTripper
+	jar          http.CookieJar
+	timeout      time.Duration
+	maxRedirects int // -1 means "use each section's follow_redirects config"
+	noFollow     bool
+}
+
+// newHTTPClient builds an *http.Client whose redirect handling is
+// resolved from sectionName, the section of the page or link the request
+// originated from (not the redirect target's host, which may be an
+// unrelated CDN domain and have no section of its own). sectionName is
+// fixed for the lifetime of the client, so every hop of a redirect chain
+// is judged against the same section. capture, when non-nil, is called
+// with any Location matched by that section's capture_redirect_regex.
+func newHTTPClient(cfg *ini.File, hc httpClientConfig, sectionName string, capture func(location string)) *http.Client {
+	return &http.Client{
+		Transport: hc.transport,
+		Jar:       hc.jar,
+		Timeout:   hc.timeout,
+		CheckRedirect: func(req *http.Request, via []*http.Request) error {
+			policy := redirectPolicy{follow: true, max: 10}
+			if section, err := cfg.GetSection(sectionName); err == nil {
+				policy = resolveRedirectPolicy(section)
+			}
+			if capture != nil && policy.captureRegex != nil && policy.captureRegex.MatchString(req.URL.String()) {
+				capture(req.URL.String())
+				return http.ErrUseLastResponse
+			}
+			if hc.noFollow || !policy.follow {
+				return http.ErrUseLastResponse
+			}
+			maxRedirects := policy.max
+			if hc.maxRedirects >= 0 {
+				maxRedirects = hc.maxRedirects
+			}
+			if len(via) >= maxRedirects {
+				return fmt.Errorf("stopped after %d redirects", maxRedirects)
+			}
+			return nil
+		},
+	}
+}
+
+// downloadTask bundles everything downloadURL needs to fetch one file,
+// including what the HEAD probe learned about it, the resolved parallel
+// part count (1 disables segmentation), and the section (of the page
+// that linked to it) whose redirect policy governs its fetches.
+type downloadTask struct {
+	url          string
+	filepath     string
+	sectionName  string
+	size         int64 // 0 when unknown
+	acceptRanges bool
+	parts        int
+}
+
+// syncCapture buffers captured redirect Locations safely for use from
+// segmentedDownload's concurrent chunk goroutines. Its contents are only
+// safe to read after every goroutine given access to add has finished.
+type syncCapture struct {
+	mu   sync.Mutex
+	urls []string
+}
+
+func (c *syncCapture) add(location string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.urls = append(c.urls, location)
+}
+
+// downloadPartState is the .part sidecar persisted next to a segmented
+// download so an interrupted run can resume without re-fetching chunks
+// that already finished.
+type downloadPartState struct {
+	URL       string `json:"url"`
+	Size      int64  `json:"size"`
+	Parts     int    `json:"parts"`
+	Completed []bool `json:"completed"`
+}
+
+func partSidecarPath(filepath string) string {
+	return filepath + ".part"
+}
+
+// loadPartState reads an existing sidecar for url/size/parts, discarding
+// it (and starting fresh) if it's missing, unreadable, or describes a
+// different download.
+func loadPartState(path, url string, size int64, parts int) *downloadPartState {
+	fresh := &downloadPartState{URL: url, Size: size, Parts: parts, Completed: make([]bool, parts)}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return fresh
+	}
+	var state downloadPartState
+	if err := json.Unmarshal(data, &state); err != nil {
+		return fresh
+	}
+	if state.URL != url || state.Size != size || state.Parts != parts || len(state.Completed) != parts {
+		return fresh
+	}
+	return &state
+}
+
+func (s *downloadPartState) save(path string) error {
+	data, err := json.MarshalIndent(s, "", "  ")
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(path, data, 0o644)
+}
+
+// chunkBounds returns the inclusive byte range of chunk i of parts over a
+// file of the given size.
+func chunkBounds(size int64, parts, i int) (start, end int64) {
+	chunkSize := size / int64(parts)
+	start = int64(i) * chunkSize
+	if i == parts-1 {
+		end = size - 1
+	} else {
+		end = start + chunkSize - 1
+	}
+	return start, end
+}
+
+// httpStatusError reports an unexpected HTTP status so callers can tell
+// transient server errors (5xx) from permanent ones (4xx).
+type httpStatusError struct {
+	code int
+}
+
+func (e httpStatusError) Error() string {
+	return fmt.Sprintf("unexpected status: %d", e.code)
+}
+
+// isTransientChunkError reports whether a chunk fetch is worth retrying:
+// a 5xx response, or any error that isn't an HTTP status error (network
+// failures, and equally a failed write to the output file).
+func isTransientChunkError(err error) bool {
+	var statusErr httpStatusError
+	if errors.As(err, &statusErr) {
+		return statusErr.code >= 500
+	}
+	return true
+}
+
+// fetchChunk issues a single Range GET for [start, end] and writes the
+// response directly into out at the matching offset, reporting progress
+// on bar/totalBar as bytes arrive.
+func fetchChunk(client *http.Client, url string, out *os.File, start, end int64, bar, totalBar *mpb.Bar) error {
+	req, err := http.NewRequest(http.MethodGet, url, nil)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Range", fmt.Sprintf("bytes=%d-%d", start, end))
+	resp, err := client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusPartialContent {
+		return httpStatusError{code: resp.StatusCode}
+	}
+
+	var body io.Reader = resp.Body
+	if bar != nil || totalBar != nil {
+		body = &progressReader{Reader: resp.Body, bar: bar, total: totalBar}
+	}
+
+	buf := make([]byte, 32*1024)
+	offset := start
+	for {
+		n, rerr := body.Read(buf)
+		if n > 0 {
+			if _, werr := out.WriteAt(buf[:n], offset); werr != nil {
+				return werr
+			}
+			offset += int64(n)
+		}
+		if rerr == io.EOF {
+			return nil
+		}
+		if rerr != nil {
+			return rerr
+		}
+	}
+}
+
+// fetchChunkWithRetry retries fetchChunk with exponential backoff on
+// transient errors, giving up after a fixed number of attempts.
+func fetchChunkWithRetry(client *http.Client, url string, out *os.File, start, end int64, bar, totalBar *mpb.Bar) error {
+	const maxAttempts = 5
+	backoff := 500 * time.Millisecond
+	var lastErr error
+	for attempt := 0; attempt < maxAttempts; attempt++ {
+		if attempt > 0 {
+			time.Sleep(backoff)
+			backoff *= 2
+		}
+		err := fetchChunk(client, url, out, start, end, bar, totalBar)
+		if err == nil {
+			return nil
+		}
+		lastErr = err
+		if !isTransientChunkError(err) {
+			return err
+		}
+	}
+	return fmt.Errorf("giving up after %d attempts: %w", maxAttempts, lastErr)
+}
+
+// segmentedDownload splits task into task.parts byte-range chunks and
+// fetches them concurrently into a pre-allocated sparse file, persisting
+// a .part sidecar so a re-run resumes only the chunks that didn't finish.
+// The sidecar is removed once every chunk succeeds. capture, when
+// non-nil, is called with any Location matched by task's section's
+// capture_redirect_regex.
+//
+// Chunks the sidecar already marks Completed are never re-fetched, so
+// their bytes are credited to bar/totalBar up front: otherwise a resumed
+// download's bars would never reach their declared total, and since mpb
+// only considers a bar (and so Progress.Wait) done once current >= total,
+// main would hang forever waiting on bars that can never fill.
+func segmentedDownload(task downloadTask, bar, totalBar *mpb.Bar, cfg *ini.File, hc httpClientConfig, capture func(location string)) error {
+	sidecarPath := partSidecarPath(task.filepath)
+	state := loadPartState(sidecarPath, task.url, task.size, task.parts)
+
+	out, err := os.OpenFile(task.filepath, os.O_RDWR|os.O_CREATE, 0o644)
+	if err != nil {
+		return fmt.Errorf("could not create %s: %w", task.filepath, err)
+	}
+	defer out.Close()
+	if err := out.Truncate(task.size); err != nil {
+		return fmt.Errorf("could not allocate %s: %w", task.filepath, err)
+	}
+
+	var completedBytes int64
+	for i := 0; i < task.parts; i++ {
+		if state.Completed[i] {
+			start, end := chunkBounds(task.size, task.parts, i)
+			completedBytes += end - start + 1
+		}
+	}
+	if completedBytes > 0 {
+		if bar != nil {
+			bar.IncrInt64(completedBytes)
+		}
+		if totalBar != nil {
+			totalBar.IncrInt64(completedBytes)
+		}
+	}
+
+	client := newHTTPClient(cfg, hc, task.sectionName, capture)
+	var mu sync.Mutex
+	var wg sync.WaitGroup
+	errs := make([]error, task.parts)
+
+	for i := 0; i < task.parts; i++ {
+		if state.Completed[i] {
+			continue
+		}
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			start, end := chunkBounds(task.size, task.parts, i)
+			if err := fetchChunkWithRetry(client, task.url, out, start, end, bar, totalBar); err != nil {
+				errs[i] = err
+				return
+			}
+			mu.Lock()
+			state.Completed[i] = true
+			state.save(sidecarPath)
+			mu.Unlock()
+		}(i)
+	}
+	wg.Wait()
+
+	for _, err := range errs {
+		if err != nil {
+			return err
+		}
+	}
+	os.Remove(sidecarPath)
+	return nil
+}
+
 // findConfigurationFile remains the same.
 func findConfigurationFile() (string, error) {
 	executableName, err := os.Executable()
@@ -76,7 +564,7 @@ func findConfigurationFile() (string, error) {
 }
 
 // processURL is the worker that parses the initial page.
-func processURL(arg string, cfg *ini.File, wg *sync.WaitGroup, results chan<- ProcessResult, keepTemporary bool) {
+func processURL(arg string, cfg *ini.File, wg *sync.WaitGroup, results chan<- ProcessResult, keepTemporary bool, decompressMode string, hc httpClientConfig) {
 	defer wg.Done()
 	res := ProcessResult{URL: arg}
 
@@ -94,6 +582,7 @@ func processURL(arg string, cfg *ini.File, wg *sync.WaitGroup, results chan<- Pr
 		return
 	}
 	sectionName := parts[len(parts)-2]
+	res.SectionName = sectionName
 	res.OutputMessages = append(res.OutputMessages, fmt.Sprintf("Processing section '%s'...", sectionName))
 	section, err := cfg.GetSection(sectionName)
 	if err != nil {
@@ -115,18 +604,48 @@ func processURL(arg string, cfg *ini.File, wg *sync.WaitGroup, results chan<- Pr
 		results <- res
 		return
 	}
-	resp, err := http.Get(arg)
+	client := newHTTPClient(cfg, hc, sectionName, func(location string) {
+		res.FoundURLs = append(res.FoundURLs, location)
+	})
+	resp, err := client.Get(arg)
 	if err != nil {
 		res.Err = fmt.Errorf("failed to download: %w", err)
 		results <- res
 		return
 	}
 	defer resp.Body.Close()
+
+	// A redirect response with err == nil only happens when CheckRedirect
+	// returned http.ErrUseLastResponse (captured by capture_redirect_regex,
+	// or follow_redirects=false/-no-follow): that's this section's policy
+	// deliberately stopping here, not a failed download. Report it as such
+	// and hand back whatever locations were captured rather than erroring
+	// out and discarding them.
+	if resp.StatusCode >= 300 && resp.StatusCode < 400 && resp.Header.Get("Location") != "" {
+		res.OutputMessages = append(res.OutputMessages, fmt.Sprintf("  Stopped at redirect (%s) without following.", resp.Status))
+		res.FoundURLs = dedupeStrings(res.FoundURLs)
+		res.FinalPrefix = cleanPrefix(res.FinalPrefix)
+		results <- res
+		return
+	}
 	if resp.StatusCode != http.StatusOK {
 		res.Err = fmt.Errorf("download failed with status: %s", resp.Status)
 		results <- res
 		return
 	}
+
+	body := io.ReadCloser(resp.Body)
+	if decompressMode != "off" {
+		decoded, err := decompress.Open(resp.Body, resp.Header.Get("Content-Type"), resp.Header.Get("Content-Encoding"), arg)
+		if err != nil {
+			res.Err = fmt.Errorf("failed to decompress response: %w", err)
+			results <- res
+			return
+		}
+		body = decoded
+	}
+	defer body.Close()
+
 	tmpFile, err := os.CreateTemp("", "regexdownload-*.tmp")
 	if err != nil {
 		res.Err = fmt.Errorf("failed to create temp file: %w", err)
@@ -134,7 +653,7 @@ func processURL(arg string, cfg *ini.File, wg *sync.WaitGroup, results chan<- Pr
 		return
 	}
 
-	_, err = io.Copy(tmpFile, resp.Body)
+	_, err = io.Copy(tmpFile, body)
 	tmpFile.Close()
 	if err != nil {
 		os.Remove(tmpFile.Name())
@@ -179,8 +698,19 @@ func processURL(arg string, cfg *ini.File, wg *sync.WaitGroup, results chan<- Pr
 					res.FoundURLs = append(res.FoundURLs, string(match[1]))
 				}
 			}
+			continue
+		}
+		if prefix, ok := extractorPrefix(key.Name()); ok {
+			extractor, _ := extractors.For(prefix, key.String())
+			found, err := extractor.Extract(content, parsedURL)
+			if err != nil {
+				res.OutputMessages = append(res.OutputMessages, fmt.Sprintf("  Warning: %s extractor for key '%s' failed: %v", prefix, key.Name(), err))
+				continue
+			}
+			res.FoundURLs = append(res.FoundURLs, found...)
 		}
 	}
+	res.FoundURLs = dedupeStrings(res.FoundURLs)
 
 	// --- MODIFIED LOGIC for keeping the temporary file ---
 	if keepTemporary {
@@ -203,37 +733,81 @@ func processURL(arg string, cfg *ini.File, wg *sync.WaitGroup, results chan<- Pr
 	results <- res
 }
 
-// downloadURL function remains the same.
-func downloadURL(url, filepath string, wg *sync.WaitGroup, results chan<- DownloadResult) {
+// downloadURL fetches url into filepath. bar and totalBar are optional
+// (nil when progress reporting is disabled) and are incremented as the
+// body is streamed to disk. Any Location matched by task's section's
+// capture_redirect_regex is collected into the result's CapturedURLs.
+func downloadURL(task downloadTask, wg *sync.WaitGroup, results chan<- DownloadResult, bar, totalBar *mpb.Bar, cfg *ini.File, hc httpClientConfig) {
 	defer wg.Done()
-	res := DownloadResult{URL: url, Filepath: filepath}
+	res := DownloadResult{URL: task.url, Filepath: task.filepath}
+	var captured syncCapture
 
-	resp, err := http.Get(url)
+	if task.parts > 1 && task.acceptRanges && task.size > 0 {
+		if err := segmentedDownload(task, bar, totalBar, cfg, hc, captured.add); err != nil {
+			if bar != nil {
+				bar.Abort(true)
+			}
+			res.Err = err
+		}
+		res.CapturedURLs = dedupeStrings(captured.urls)
+		results <- res
+		return
+	}
+
+	client := newHTTPClient(cfg, hc, task.sectionName, captured.add)
+	resp, err := client.Get(task.url)
 	if err != nil {
+		if bar != nil {
+			bar.Abort(true)
+		}
 		res.Err = err
 		results <- res
 		return
 	}
 	defer resp.Body.Close()
 
+	// As in processURL: a redirect response with err == nil only happens
+	// when CheckRedirect stopped deliberately (capture_redirect_regex, or
+	// follow_redirects=false/-no-follow), not a failed download. There's
+	// nothing left to fetch, but any captured Location still needs to
+	// reach the caller.
+	if resp.StatusCode >= 300 && resp.StatusCode < 400 && resp.Header.Get("Location") != "" {
+		res.OutputMessages = append(res.OutputMessages, fmt.Sprintf("Stopped at redirect (%s) without following: %s", resp.Status, task.url))
+		res.CapturedURLs = dedupeStrings(captured.urls)
+		results <- res
+		return
+	}
+
 	if resp.StatusCode != http.StatusOK {
+		if bar != nil {
+			bar.Abort(true)
+		}
 		res.Err = fmt.Errorf("bad status: %s", resp.Status)
 		results <- res
 		return
 	}
 
-	out, err := os.Create(filepath)
+	out, err := os.Create(task.filepath)
 	if err != nil {
+		if bar != nil {
+			bar.Abort(true)
+		}
 		res.Err = err
 		results <- res
 		return
 	}
 	defer out.Close()
 
-	_, err = io.Copy(out, resp.Body)
+	var body io.Reader = resp.Body
+	if bar != nil || totalBar != nil {
+		body = &progressReader{Reader: resp.Body, bar: bar, total: totalBar}
+	}
+
+	_, err = io.Copy(out, body)
 	if err != nil {
 		res.Err = err
 	}
+	res.CapturedURLs = dedupeStrings(captured.urls)
 	results <- res
 }
 
@@ -244,8 +818,18 @@ func main() {
 	keepTemporary := flag.Bool("k", false, "Keep temporary downloaded files for debugging")
 	flag.BoolVar(keepTemporary, "keep", false, "Keep temporary downloaded files for debugging")
 	flag.BoolVar(keepTemporary, "keep-temporary", false, "Keep temporary downloaded files for debugging")
+	showProgress := flag.Bool("progress", term.IsTerminal(int(os.Stderr.Fd())), "Show per-file and total progress bars on stderr")
+	decompressMode := flag.String("decompress", "auto", "Decompress fetched content before matching: auto or off")
+	maxRedirects := flag.Int("max-redirects", -1, "Maximum redirects to follow, overriding each section's follow_redirects (-1 defers to config)")
+	noFollow := flag.Bool("no-follow", false, "Never follow redirects; return the redirect response as-is")
+	parallelParts := flag.Int("parallel-parts", -1, "Split large downloads into N concurrent range requests, overriding each section's parts= (-1 defers to config, which defaults to 1)")
 	flag.Parse()
 
+	if *decompressMode != "auto" && *decompressMode != "off" {
+		fmt.Fprintf(os.Stderr, "Error: -decompress must be 'auto' or 'off', got %q\n", *decompressMode)
+		os.Exit(1)
+	}
+
 	configFile, err := findConfigurationFile()
 	if err != nil {
 		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
@@ -267,11 +851,32 @@ func main() {
 		os.Exit(1)
 	}
 
+	defaultSection := cfg.Section("")
+	var timeout time.Duration
+	if secs, err := defaultSection.Key("timeout_seconds").Int(); err == nil {
+		timeout = time.Duration(secs) * time.Second
+	}
+	var jar http.CookieJar
+	if cookiesPath := defaultSection.Key("cookies").String(); cookiesPath != "" {
+		jar, err = loadCookieJar(cookiesPath)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Warning: %v\n", err)
+			jar = nil
+		}
+	}
+	hc := httpClientConfig{
+		transport:    &userAgentTransport{rt: http.DefaultTransport, userAgent: defaultSection.Key("user_agent").String()},
+		jar:          jar,
+		timeout:      timeout,
+		maxRedirects: *maxRedirects,
+		noFollow:     *noFollow,
+	}
+
 	parseResults := make(chan ProcessResult, len(args))
 	var parseWg sync.WaitGroup
 	for _, arg := range args {
 		parseWg.Add(1)
-		go processURL(arg, cfg, &parseWg, parseResults, *keepTemporary)
+		go processURL(arg, cfg, &parseWg, parseResults, *keepTemporary, *decompressMode, hc)
 	}
 	parseWg.Wait()
 	close(parseResults)
@@ -284,10 +889,30 @@ func main() {
 	if *verbose {
 		fmt.Println("\n--- Starting Download Phase ---")
 	}
-	downloadResults := make(chan DownloadResult)
-	var downloadWg sync.WaitGroup
-	totalDownloads := 0
 
+	// resolveParts picks the parallel part count for a download: the
+	// -parallel-parts flag, if given, overrides every section; otherwise
+	// the section's own "parts" key applies; absent both, segmentation is
+	// off.
+	resolveParts := func(sectionName string) int {
+		if *parallelParts >= 1 {
+			return *parallelParts
+		}
+		if sectionName == "" {
+			return 1
+		}
+		section, err := cfg.GetSection(sectionName)
+		if err != nil || !section.HasKey("parts") {
+			return 1
+		}
+		n, err := section.Key("parts").Int()
+		if err != nil || n < 1 {
+			return 1
+		}
+		return n
+	}
+
+	var jobs []downloadTask
 	for _, res := range processed {
 		if *verbose {
 			for _, msg := range res.OutputMessages {
@@ -306,6 +931,7 @@ func main() {
 			continue
 		}
 
+		parts := resolveParts(res.SectionName)
 		for i, urlToDownload := range res.FoundURLs {
 			fileIndex := i + 1
 			extension := path.Ext(urlToDownload)
@@ -313,13 +939,65 @@ func main() {
 				extension = ".unknown"
 			}
 			fileName := fmt.Sprintf("%s-%02d%s", res.FinalPrefix, fileIndex, extension)
+			jobs = append(jobs, downloadTask{url: urlToDownload, filepath: fileName, sectionName: res.SectionName, parts: parts})
+		}
+	}
+
+	// Verbose output above is printed before the pool exists, so it never
+	// collides with the bars that follow. Each job gets its own client so
+	// the HEAD probe's redirect policy (and any capture_redirect_regex)
+	// is resolved from the section that produced the job, same as the
+	// download itself.
+	for i, job := range jobs {
+		var captured syncCapture
+		client := newHTTPClient(cfg, hc, job.sectionName, captured.add)
+		info := probeHead(client, job.url)
+		jobs[i].size = info.size
+		jobs[i].acceptRanges = info.acceptRanges
+		if *verbose {
+			for _, loc := range captured.urls {
+				fmt.Printf("  Captured redirect during HEAD probe for %s: %s\n", job.url, loc)
+			}
+		}
+	}
 
-			downloadWg.Add(1)
-			totalDownloads++
-			go downloadURL(urlToDownload, fileName, &downloadWg, downloadResults)
+	var pool *mpb.Progress
+	var totalBar *mpb.Bar
+	bars := make([]*mpb.Bar, len(jobs))
+	if *showProgress && len(jobs) > 0 {
+		pool = mpb.New(mpb.WithOutput(os.Stderr))
+		var totalSize int64
+		sizesKnown := true
+		for _, job := range jobs {
+			if job.size > 0 {
+				totalSize += job.size
+			} else {
+				sizesKnown = false
+			}
+		}
+		if sizesKnown {
+			totalBar = newSizedOrSpinnerBar(pool, "Total", totalSize)
+		} else {
+			totalBar = newSizedOrSpinnerBar(pool, "Total", 0)
+		}
+		for i, job := range jobs {
+			bars[i] = newSizedOrSpinnerBar(pool, filepath.Base(job.filepath), job.size)
 		}
 	}
 
+	downloadResults := make(chan DownloadResult)
+	var downloadWg sync.WaitGroup
+	totalDownloads := len(jobs)
+
+	for i, job := range jobs {
+		downloadWg.Add(1)
+		var bar *mpb.Bar
+		if pool != nil {
+			bar = bars[i]
+		}
+		go downloadURL(job, &downloadWg, downloadResults, bar, totalBar, cfg, hc)
+	}
+
 	go func() {
 		downloadWg.Wait()
 		close(downloadResults)
@@ -327,9 +1005,21 @@ func main() {
 
 	for i := 0; i < totalDownloads; i++ {
 		res := <-downloadResults
+		if *verbose {
+			for _, msg := range res.OutputMessages {
+				fmt.Println(msg)
+			}
+			for _, loc := range res.CapturedURLs {
+				fmt.Printf("  Captured redirect for %s: %s\n", res.URL, loc)
+			}
+		}
 		if res.Err != nil {
-			fmt.Fprintf(os.Stderr, "Error downloading %s: %v\n", res.URL, res.Err)
-		} else {
+			// Written through pool (when live) rather than straight to
+			// os.Stderr: the pool's own redraws are already writing
+			// ANSI-positioned frames to that fd, and a direct concurrent
+			// write here would interleave with and garble them.
+			printDuringProgress(pool, "Error downloading %s: %v\n", res.URL, res.Err)
+		} else if len(res.OutputMessages) == 0 {
 			if *verbose {
 				fmt.Printf("%s -> %s\n", res.URL, res.Filepath)
 			} else {
@@ -337,6 +1027,9 @@ func main() {
 			}
 		}
 	}
+	if pool != nil {
+		pool.Wait()
+	}
 	if *verbose {
 		fmt.Println("--- Download Phase Complete ---")
 	}